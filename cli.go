@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// protocolSubcommand builds a subcommand that pins -M/--module to name and
+// runs with whatever target URL the user gives it, so e.g. "hydra ssh
+// host:22" needs no -M flag at all.
+func protocolSubcommand(name, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " URL",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			*module = name
+			runHydra(args)
+		},
+	}
+}
+
+// checkSessionCmd inspects a -session checkpoint file without attempting
+// any logins, so an operator can see how far a run got before deciding
+// whether to -resume it.
+var checkSessionCmd = &cobra.Command{
+	Use:   "check-session FILE",
+	Short: "Print the progress recorded in a -session checkpoint file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cp, err := loadCheckpoint(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("input hash: %s\nattempted:  %d\npending:    %d\n", cp.InputHash, cp.Produced-len(cp.Pending), len(cp.Pending))
+	},
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "hydra",
+	Short: "A login/password brute-forcer for HTTP forms and common network services",
+	Long:  usage,
+	Args:  cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runHydra(args)
+	},
+}
+
+func init() {
+	pflag.CommandLine.Var(&headersAdd, "header", "Add an HTTP header")
+	pflag.CommandLine.VarP(&headersReplace, "header-replace", "H", "Replace an HTTP header")
+	rootCmd.PersistentFlags().AddFlagSet(pflag.CommandLine)
+
+	rootCmd.AddCommand(
+		&cobra.Command{
+			Use:   "http-post URL post-data condition",
+			Short: "Brute-force an HTTP POST login form (the default mode)",
+			Args:  cobra.ExactArgs(3),
+			Run: func(cmd *cobra.Command, args []string) {
+				*module = ""
+				*httpMode = "post"
+				runHydra(args)
+			},
+		},
+		protocolSubcommand("ssh", "Brute-force SSH logins"),
+		protocolSubcommand("ftp", "Brute-force FTP logins"),
+		protocolSubcommand("mongodb", "Brute-force MongoDB logins"),
+		checkSessionCmd,
+	)
+}
+
+// legacyHeaderValue reports whether val looks like the "key: value" header
+// argument the pre-migration CLI's "-h" flag (flag.Var(&headersAdd, "h",
+// ...)) always took, as opposed to a bare -h asking for --help.
+func legacyHeaderValue(val string) bool {
+	return strings.Contains(val, ":")
+}
+
+// normalizeLegacyArgs rewrites old single-dash spellings of multi-character
+// flags (e.g. "-session", "-backoff-regex") to their double-dash form so
+// pflag, which treats a single dash followed by more than one letter as a
+// cluster of shorthand flags, still accepts command lines written for the
+// tool's previous stdlib-flag-based CLI. Genuine shorthands (-l, -t16, ...)
+// are left untouched.
+//
+// "-h" needs its own case: the pre-migration CLI used it for add-header,
+// but cobra auto-registers -h as the --help shorthand, so a bare pass
+// through would silently swallow it as a help request instead. A legacy
+// "-h"/"-h=..." is always followed by a "key: value" header, which --help
+// never takes, so that's the signal used to tell the two apart.
+func normalizeLegacyArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+
+		if strings.HasPrefix(a, "-h=") && legacyHeaderValue(a[len("-h="):]) {
+			out = append(out, "--header="+a[len("-h="):])
+			continue
+		}
+		if a == "-h" && i+1 < len(args) && legacyHeaderValue(args[i+1]) {
+			out = append(out, "--header", args[i+1])
+			i++
+			continue
+		}
+
+		if len(a) > 2 && a[0] == '-' && a[1] != '-' {
+			name := a[1:]
+			if eq := strings.IndexByte(name, '='); eq >= 0 {
+				name = name[:eq]
+			}
+			if len(name) > 1 && pflag.CommandLine.Lookup(name) != nil {
+				a = "-" + a
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func main() {
+	log.SetFlags(log.Lshortfile)
+
+	os.Args = append(os.Args[:1], normalizeLegacyArgs(os.Args[1:])...)
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLegacyHeaderValue(t *testing.T) {
+	cases := []struct {
+		val  string
+		want bool
+	}{
+		{"Authorization: Bearer xyz", true},
+		{"X-Forwarded-For: 1.2.3.4", true},
+		{"--help", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := legacyHeaderValue(c.val); got != c.want {
+			t.Errorf("legacyHeaderValue(%q) = %v, want %v", c.val, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeLegacyArgsRewritesSingleDashLongFlags(t *testing.T) {
+	got := normalizeLegacyArgs([]string{"-output-format", "csv", "-rate", "5"})
+	want := []string{"--output-format", "csv", "--rate", "5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeLegacyArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeLegacyArgsLeavesShorthandsAlone(t *testing.T) {
+	got := normalizeLegacyArgs([]string{"-l", "admin", "-t16"})
+	want := []string{"-l", "admin", "-t16"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeLegacyArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeLegacyArgsHEqualsHeaderValue(t *testing.T) {
+	got := normalizeLegacyArgs([]string{"-h=Authorization: Bearer xyz"})
+	want := []string{"--header=Authorization: Bearer xyz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeLegacyArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeLegacyArgsHSpaceHeaderValue(t *testing.T) {
+	got := normalizeLegacyArgs([]string{"-h", "Authorization: Bearer xyz", "-l", "admin"})
+	want := []string{"--header", "Authorization: Bearer xyz", "-l", "admin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeLegacyArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeLegacyArgsBareHStaysHelp(t *testing.T) {
+	got := normalizeLegacyArgs([]string{"-h"})
+	want := []string{"-h"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeLegacyArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeLegacyArgsHBeforeNonHeaderArgStaysHelp(t *testing.T) {
+	got := normalizeLegacyArgs([]string{"-h", "ssh"})
+	want := []string{"-h", "ssh"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeLegacyArgs() = %v, want %v", got, want)
+	}
+}
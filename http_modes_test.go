@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/md4"
+)
+
+func TestParseAuthParams(t *testing.T) {
+	challenge := `Digest realm="example.com", qop="auth", nonce="abc123", opaque="xyz"`
+	params := parseAuthParams(challenge)
+
+	want := map[string]string{"realm": "example.com", "qop": "auth", "nonce": "abc123", "opaque": "xyz"}
+	for k, v := range want {
+		if params[k] != v {
+			t.Errorf("params[%q] = %q, want %q", k, params[k], v)
+		}
+	}
+}
+
+func TestBuildDigestHeaderNoQop(t *testing.T) {
+	params := map[string]string{"realm": "example.com", "nonce": "abc123"}
+	header := buildDigestHeader(params, "GET", "/private", "admin", "hunter2")
+
+	ha1 := md5Hex("admin:example.com:hunter2")
+	ha2 := md5Hex("GET:/private")
+	wantResponse := md5Hex(ha1 + ":abc123:" + ha2)
+
+	if !containsAll(header, []string{
+		`username="admin"`, `realm="example.com"`, `nonce="abc123"`, `uri="/private"`,
+		`response="` + wantResponse + `"`,
+	}) {
+		t.Fatalf("buildDigestHeader() = %q, missing expected fields (want response %q)", header, wantResponse)
+	}
+	if containsAll(header, []string{"qop="}) {
+		t.Fatalf("buildDigestHeader() without qop should not include a qop field: %q", header)
+	}
+}
+
+func TestBuildDigestHeaderWithQop(t *testing.T) {
+	params := map[string]string{"realm": "example.com", "nonce": "abc123", "qop": "auth"}
+	header := buildDigestHeader(params, "GET", "/private", "admin", "hunter2")
+
+	if !containsAll(header, []string{`qop=auth`, `nc=00000001`, `cnonce="`}) {
+		t.Fatalf("buildDigestHeader() with qop missing expected fields: %q", header)
+	}
+}
+
+func containsAll(s string, subs []string) bool {
+	for _, sub := range subs {
+		if !contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(s, sub string) bool {
+	return len(sub) == 0 || (len(s) >= len(sub) && indexOf(s, sub) >= 0)
+}
+
+func indexOf(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestUTF16LE(t *testing.T) {
+	got := utf16le("AB")
+	want := []byte{'A', 0, 'B', 0}
+	if len(got) != len(want) {
+		t.Fatalf("utf16le() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("utf16le() = %v, want %v", got, want)
+		}
+	}
+}
+
+// TestNTHashMatchesKnownVector checks the MD4(UTF-16LE(password)) NT hash
+// that ntlmv1Response builds its DES keys from against the widely published
+// MS-NLMP example for password "Password".
+func TestNTHashMatchesKnownVector(t *testing.T) {
+	h := md4.New()
+	h.Write(utf16le("Password"))
+	got := h.Sum(nil)
+
+	want, err := hex.DecodeString("a4f49c406510bdcab6824ee7c30fd852")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 16 {
+		t.Fatalf("test vector decoded to %d bytes, want 16", len(want))
+	}
+
+	if hex.EncodeToString(got) != hex.EncodeToString(want) {
+		t.Fatalf("MD4(UTF-16LE(%q)) = %x, want %x", "Password", got, want)
+	}
+}
+
+// TestNTLMv1ResponseIsDeterministicAndKeyDependent exercises the full
+// ntlmv1Response chain (NT hash, DES key expansion/parity, triple-DES of the
+// challenge): same inputs must always produce the same 24-byte response, and
+// changing the password or the challenge must change it.
+func TestNTLMv1ResponseIsDeterministicAndKeyDependent(t *testing.T) {
+	challenge, err := hex.DecodeString("0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ntlmv1Response("hunter2", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 24 {
+		t.Fatalf("ntlmv1Response() length = %d, want 24", len(got))
+	}
+
+	again, err := ntlmv1Response("hunter2", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(got) != hex.EncodeToString(again) {
+		t.Fatalf("ntlmv1Response() is not deterministic: %x != %x", got, again)
+	}
+
+	otherPass, err := ntlmv1Response("different", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(got) == hex.EncodeToString(otherPass) {
+		t.Fatal("ntlmv1Response() did not change with the password")
+	}
+
+	otherChallenge, err := hex.DecodeString("fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherResp, err := ntlmv1Response("hunter2", otherChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(got) == hex.EncodeToString(otherResp) {
+		t.Fatal("ntlmv1Response() did not change with the challenge")
+	}
+}
+
+// TestExpandDESKeySetsOddParity checks expandDESKey's defining property:
+// every output byte must have odd parity, as crypto/des requires.
+func TestExpandDESKeySetsOddParity(t *testing.T) {
+	key7 := []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}
+	key8 := expandDESKey(key7)
+	if len(key8) != 8 {
+		t.Fatalf("expandDESKey() length = %d, want 8", len(key8))
+	}
+	for i, b := range key8 {
+		ones := 0
+		for bit := 0; bit < 8; bit++ {
+			if b&(1<<uint(bit)) != 0 {
+				ones++
+			}
+		}
+		if ones%2 != 1 {
+			t.Fatalf("expandDESKey()[%d] = %#x does not have odd parity", i, b)
+		}
+	}
+}
+
+func TestNTLMNegotiateMessageHeader(t *testing.T) {
+	msg := ntlmNegotiateMessage()
+	if string(msg[:7]) != "NTLMSSP" {
+		t.Fatalf("ntlmNegotiateMessage() missing NTLMSSP signature: %x", msg)
+	}
+	if len(msg) != 32 {
+		t.Fatalf("ntlmNegotiateMessage() length = %d, want 32", len(msg))
+	}
+}
+
+func TestNTLMExtractChallenge(t *testing.T) {
+	// A type-2 message: 8-byte signature, 4-byte type, then padding up to
+	// the 8-byte challenge at offset 24.
+	type2 := make([]byte, 32)
+	copy(type2, []byte("NTLMSSP\x00"))
+	copy(type2[24:32], []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef})
+
+	header := "NTLM " + base64.StdEncoding.EncodeToString(type2)
+	nonce, err := ntlmExtractChallenge(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	if hex.EncodeToString(nonce) != hex.EncodeToString(want) {
+		t.Fatalf("ntlmExtractChallenge() = %x, want %x", nonce, want)
+	}
+}
+
+func TestNTLMExtractChallengeNoNTLMField(t *testing.T) {
+	if _, err := ntlmExtractChallenge("Negotiate abcdef"); err == nil {
+		t.Fatal("expected an error when no NTLM field is present")
+	}
+}
@@ -16,23 +16,22 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
 )
 
 const (
@@ -41,22 +40,26 @@ const (
 )
 
 var (
-	loginsStr          = flag.String("l", "", "A login or logins separated by colons")
-	loginsFrom         = flag.String("L", "", "Load logins from FILE")
-	passwordsStr       = flag.String("p", "", "A password or passwords separated by colons")
-	passwordsFrom      = flag.String("P", "", "Load passwords from FILE")
-	colonSeparatedFrom = flag.String("C", "", `Load lines in the colon separated "login:pass" format from FILE`)
-	firstOnly          = flag.Bool("f", false, "Exit when a login/password pair is found")
-	invertedCondition  = flag.Bool("i", false, "A fulfilled condition means an attempt was successful")
-	conditionIsRegexp  = flag.Bool("regex", false, "The condition is a regular expression")
-	numTasks           = flag.Int("t", 16, "A number of tasks to run in parallel")
-	verbose            = flag.Bool("v", false, "Be verbose (show the response from the HTTP server)")
-	showAttempts       = flag.Bool("V", false, "Show login+password for each attempt")
-	outputTo           = flag.String("o", "", "Write found login/password pairs to FILE instead of stdout")
+	loginsStr          = pflag.StringP("login", "l", "", "A login or logins separated by colons")
+	loginsFrom         = pflag.StringP("login-file", "L", "", "Load logins from FILE")
+	passwordsStr       = pflag.StringP("password", "p", "", "A password or passwords separated by colons")
+	passwordsFrom      = pflag.StringP("password-file", "P", "", "Load passwords from FILE")
+	colonSeparatedFrom = pflag.StringP("combo-file", "C", "", `Load lines in the colon separated "login:pass" format from FILE`)
+	firstOnly          = pflag.BoolP("first-found", "f", false, "Exit when a login/password pair is found")
+	invertedCondition  = pflag.BoolP("invert-condition", "i", false, "A fulfilled condition means an attempt was successful")
+	conditionIsRegexp  = pflag.Bool("regex", false, "The condition is a regular expression")
+	numTasks           = pflag.IntP("tasks", "t", 16, "A number of tasks to run in parallel")
+	verbose            = pflag.BoolP("verbose", "v", false, "Be verbose (show the response from the HTTP server)")
+	showAttempts       = pflag.BoolP("show-attempts", "V", false, "Show login+password for each attempt")
+	outputTo           = pflag.StringP("output-file", "o", "", "Write found login/password pairs to FILE instead of stdout")
 	headersAdd         Headers
 	headersReplace     Headers
 
-	retryQueueLength = flag.Int("r", 1024, "Length of the retry queue")
+	retryQueueLength = pflag.IntP("retry-queue", "r", 1024, "Length of the retry queue")
+
+	module = pflag.StringP("module", "M", "", "Protocol module to use (ssh, ftp, mongodb) instead of HTTP POST form mode")
+
+	proxyFlag = pflag.String("proxy", "", "Proxy URL to use for requests (overrides HYDRA_PROXY)")
 
 	postURL    string
 	host       string
@@ -64,6 +67,9 @@ var (
 	condition  []byte
 	rCondition *regexp.Regexp
 
+	targetHost string
+	targetPort int
+
 	jobs  chan Job
 	retry chan Job
 	wg    sync.WaitGroup
@@ -104,9 +110,15 @@ func (hs *Headers) String() string {
 	return strings.Join(s, "\n")
 }
 
+// Type satisfies pflag.Value in addition to flag.Value.
+func (hs *Headers) Type() string {
+	return "header"
+}
+
 type Job struct {
 	user string
 	pass string
+	idx  int // position in the login/password pair sequence, for -session
 }
 
 func readlines(fn string) (lines []string) {
@@ -138,13 +150,26 @@ func safeExit() {
 	os.Exit(0)
 }
 
+// worker drains jobs (falling back to the retry queue once the producer is
+// done) and feeds each login/password pair to either the active protocol
+// module or, in the default form-login mode, the built-in HTTP client.
 func worker(n int) {
 	defer wg.Done()
 
-	client := http.Client{}
-	if proxyURL != nil {
-		client.Transport = &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
+	var proto Protocol
+	var client http.Client
+	if *module != "" {
+		var err error
+		proto, err = newProtocol(*module)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		client = http.Client{}
+		if proxyURL != nil {
+			client.Transport = &http.Transport{
+				Proxy: http.ProxyURL(proxyURL),
+			}
 		}
 	}
 
@@ -153,14 +178,15 @@ func worker(n int) {
 loop:
 	for {
 		if ok {
+			// jobs is still open: block for either a fresh job or a
+			// retry, rather than giving up the moment both are
+			// momentarily empty.
 			select {
 			case job, ok = <-jobs:
 				if !ok {
 					continue loop
 				}
 			case job = <-retry:
-			default:
-				break loop
 			}
 		} else {
 			select {
@@ -170,30 +196,23 @@ loop:
 			}
 		}
 
+		limiter.wait()
+		lockout.wait()
+		backoff.wait(host)
+
 		if *showAttempts {
 			fmt.Fprintf(os.Stderr, "[ATTEMPT] target %s - login %q - pass %q [worker %d]\n", host, job.user, job.pass, n)
 		}
 
-		postData := strings.Replace(data, "^USER^", url.QueryEscape(job.user), -1)
-		postData = strings.Replace(postData, "^PASS^", url.QueryEscape(job.pass), -1)
-		req, _ := http.NewRequest("POST", postURL, strings.NewReader(postData))
-
-		req.Header.Add("Host", host)
-		req.Header.Add("User-Agent", defaultUserAgent)
-		req.Header.Add("Content-Length", strconv.Itoa(len(postData)))
-		req.Header.Add("Content-Type", defaultContentType)
-		req.Header.Add("Connection", "Keep-Alive")
-
-		for _, h := range headersAdd {
-			req.Header.Add(h.key, h.value)
-		}
-		for _, h := range headersReplace {
-			req.Header.Set(h.key, h.value)
+		start := time.Now()
+		var success bool
+		var err error
+		var respBytes int
+		if proto != nil {
+			success, err = proto.Attempt(context.Background(), targetHost, targetPort, job.user, job.pass)
+		} else {
+			success, respBytes, err = httpAttempt(&client, job)
 		}
-
-		client.Jar, _ = cookiejar.New(nil)
-
-		resp, err := client.Do(req)
 		if err != nil {
 			log.Print(err)
 			select {
@@ -202,94 +221,79 @@ loop:
 			}
 			continue
 		}
+		latency := time.Since(start)
 
-		if *verbose {
-			resp.Header.Write(os.Stderr)
-			os.Stderr.Write([]byte{'\n'})
+		if progress != nil {
+			progress.ack(job.idx)
 		}
 
-		body, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			log.Print(err)
-			select {
-			case retry <- job:
-			default:
+		if *showAttempts && *outputFormat == "jsonl" {
+			if err := writeRecord("jsonl", newAttemptRecord(job, "attempt", latency, respBytes)); err != nil {
+				log.Print(err)
 			}
-			continue
 		}
 
-		if *verbose {
-			os.Stderr.Write(body)
-		}
-
-		var failed bool
-		if rCondition != nil {
-			failed = rCondition.Match(body)
-		} else {
-			failed = bytes.Contains(body, condition)
-		}
-		if *invertedCondition {
-			failed = !failed
-		}
-		if failed {
+		if !success {
 			continue
 		}
 
-		m.Lock()
-		_, err = fmt.Fprintf(out, "%s:%s\n", job.user, job.pass)
-		m.Unlock()
-		if err != nil {
-			log.Print(err)
-		}
+		reportSuccess(job, latency, respBytes)
+	}
+}
 
-		if *firstOnly {
-			safeExit()
-		}
+// httpAttempt dispatches to the handler for the configured -m mode (see
+// http_modes.go); it defaults to the original POST form-login behaviour.
+// The int result is the response body size in bytes.
+func httpAttempt(client *http.Client, job Job) (bool, int, error) {
+	return dispatchHTTPAttempt(client, job)
+}
+
+// reportSuccess records a confirmed login/password pair and, if -f was
+// given, terminates the run.
+func reportSuccess(job Job, latency time.Duration, respBytes int) {
+	if err := writeRecord(*outputFormat, newAttemptRecord(job, "success", latency, respBytes)); err != nil {
+		log.Print(err)
+	}
+
+	if *firstOnly {
+		safeExit()
 	}
 }
 
-func main() {
-	log.SetFlags(log.Lshortfile)
-
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: hydra [options] URL post-data condition
-
-Options:
-  -l login   A login or logins separated by colons
-  -L FILE    Load logins from FILE
-  -p pass    A password or passwords separated by colons
-  -P FILE    Load passwords from FILE
-  -C FILE    Load lines in the colon separated "login:pass" format from FILE
-  -h header  Add an HTTP header
-  -H header  Replace an HTTP header
-  -i         A fulfilled condition means an attempt was successful
-  -regex     The condition is a regular expression
-  -f         Exit when a login/password pair is found
-  -t TASKS   A number of tasks to run in parallel (default: 16)
-  -o FILE    Write found login/password pairs to FILE instead of stdout
-  -v         Be verbose (show the response from the HTTP server)
-  -V         Show login+password for each attempt
-  -r         Length of the retry queue (default: 1024)
-
-Use HYDRA_PROXY environment variable for proxy setup.
-`)
-	}
-
-	flag.Var(&headersAdd, "h", "Add an HTTP header")
-	flag.Var(&headersReplace, "H", "Replace an HTTP header")
-	flag.Parse()
-	if len(flag.Args()) != 3 {
-		flag.Usage()
-		os.Exit(1)
+// usage is printed by cobra above -h/--help; it only covers the parts of
+// the command line that aren't ordinary flags (positional arguments).
+const usage = `hydra [flags] URL [post-data condition]
+
+post-data and condition are required in the default HTTP POST mode (and
+the other -m HTTP modes); protocol modules (-M/subcommand) take a bare
+target URL such as ssh://host:22 instead.
+
+Use HYDRA_PROXY environment variable as a fallback for --proxy.`
+
+// runHydra is the body of every hydra invocation (root command and every
+// protocol/mode subcommand); args are the command's positional arguments
+// (the target URL and, in HTTP form modes, the post-data and condition).
+func runHydra(args []string) {
+	if *module == "" && len(args) == 1 {
+		if scheme := urlScheme(args[0]); scheme != "" {
+			module = &scheme
+		}
+	}
+
+	if *module != "" {
+		if len(args) != 1 {
+			log.Fatal(usage)
+		}
+	} else if len(args) != 3 {
+		log.Fatal(usage)
 	}
 
 	if *loginsStr != "" && *loginsFrom != "" {
-		log.Fatal("both -l and -L are specified")
+		log.Fatal("both -l/--login and -L/--login-file are specified")
 	}
 
 	if *passwordsStr != "" && *passwordsFrom != "" {
-		log.Fatal("both -p and -P are specified")
+		log.Fatal("both -p/--password and -P/--password-file are specified")
 	}
 
 	if *colonSeparatedFrom != "" &&
@@ -297,33 +301,44 @@ Use HYDRA_PROXY environment variable for proxy setup.
 			*loginsFrom != "" ||
 			*passwordsStr != "" ||
 			*passwordsFrom != "") {
-		log.Fatal("both -C and one of -l/-L/-p/-P are specified")
+		log.Fatal("both -C/--combo-file and one of -l/-L/-p/-P are specified")
 	}
 
 	if *colonSeparatedFrom == "" {
 		if *loginsStr == "" && *loginsFrom == "" {
 			log.Fatal("no logins are specified")
 		}
-		if *passwordsStr == "" && *passwordsFrom == "" {
+		if *maskFlag == "" && *passwordsStr == "" && *passwordsFrom == "" {
 			log.Fatal("no passwords are specified")
 		}
 	}
 
-	postURL = flag.Arg(0)
+	postURL = args[0]
 	parsed, err := url.Parse(postURL)
 	if err != nil {
 		log.Fatal("invalid URL: " + err.Error())
 	}
 
 	host = parsed.Host
-	data = flag.Arg(1)
-	if *conditionIsRegexp {
-		rCondition = regexp.MustCompile(flag.Arg(2))
+
+	if *module != "" {
+		targetHost, targetPort, err = splitHostPort(parsed, *module)
+		if err != nil {
+			log.Fatal(err)
+		}
 	} else {
-		condition = []byte(flag.Arg(2))
+		data = args[1]
+		if *conditionIsRegexp {
+			rCondition = regexp.MustCompile(args[2])
+		} else {
+			condition = []byte(args[2])
+		}
 	}
 
-	proxy := os.Getenv("HYDRA_PROXY")
+	proxy := *proxyFlag
+	if proxy == "" {
+		proxy = os.Getenv("HYDRA_PROXY")
+	}
 	if proxy != "" {
 		proxyURL, err = url.Parse(proxy)
 		if err != nil {
@@ -331,49 +346,61 @@ Use HYDRA_PROXY environment variable for proxy setup.
 		}
 	}
 
-	retry = make(chan Job, *retryQueueLength)
-	jobs = make(chan Job, *numTasks)
-	wg.Add(*numTasks)
-	for i := 0; i < *numTasks; i++ {
-		go worker(i)
+	if *backoffRegexStr != "" {
+		backoffRegex = regexp.MustCompile(*backoffRegexStr)
+	}
+	if *lockoutDetect != "" {
+		lockoutRegex = regexp.MustCompile(*lockoutDetect)
 	}
+	limiter = newTokenBucket(*rateLimit)
 
-	if *outputTo != "" {
-		out, err = os.OpenFile(*outputTo, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if *maskFlag != "" && (*passwordsStr != "" || *passwordsFrom != "" || *colonSeparatedFrom != "") {
+		log.Fatal("--mask and one of -p/-P/-C are specified")
+	}
+	if *rulesFile != "" && *maskFlag != "" {
+		log.Fatal("--rules and --mask are specified")
+	}
+
+	var src pairSource
+	var inputHash string
+
+	if *maskFlag != "" {
+		var logins []string
+		if *loginsFrom != "" {
+			logins = readlines(*loginsFrom)
+		} else {
+			logins = strings.Split(*loginsStr, ":")
+		}
+
+		classes, err := parseMask(*maskFlag)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer out.Close()
 
-		sig := make(chan os.Signal)
-		signal.Notify(sig, os.Interrupt)
-		signal.Notify(sig, syscall.SIGTERM)
-
-		go func() {
-			<-sig
-			safeExit()
-		}()
-	}
-
-	if *colonSeparatedFrom != "" {
+		ms := maskSource{logins: logins, classes: classes}
+		src, inputHash = ms, ms.inputHash()
+	} else if *colonSeparatedFrom != "" {
 		f, err := os.Open(*colonSeparatedFrom)
 		if err != nil {
 			log.Fatal(err)
 		}
 		defer f.Close()
 
+		var pairs []Job
 		scanner := bufio.NewScanner(f)
 		for scanner.Scan() {
 			lp := strings.SplitN(scanner.Text(), ":", 2)
 			if len(lp) < 2 {
 				continue
 			}
-
-			jobs <- Job{lp[0], lp[1]}
+			pairs = append(pairs, Job{user: lp[0], pass: lp[1]})
 		}
 		if err := scanner.Err(); err != nil {
 			log.Fatal(err)
 		}
+
+		ls := listSource{pairs: pairs}
+		src, inputHash = ls, ls.inputHash()
 	} else {
 		var logins, passwords []string
 
@@ -389,13 +416,107 @@ Use HYDRA_PROXY environment variable for proxy setup.
 			passwords = strings.Split(*passwordsStr, ":")
 		}
 
-		for _, pass := range passwords {
-			for _, user := range logins {
-				jobs <- Job{user, pass}
+		if *rulesFile != "" {
+			rules, err := loadRules(*rulesFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, rule := range rules {
+				if err := validateRule(rule); err != nil {
+					log.Fatal(err)
+				}
 			}
+
+			rs := ruleSource{logins: logins, passwords: passwords, rules: rules}
+			src, inputHash = rs, rs.inputHash()
+		} else {
+			cs := cartesianSource{logins: logins, passwords: passwords}
+			src, inputHash = cs, cs.inputHash()
+		}
+	}
+
+	startAt, pending := resolveSession(inputHash)
+	progress = newProgressTracker(startAt)
+
+	retry = make(chan Job, *retryQueueLength)
+	jobs = make(chan Job, *numTasks)
+	wg.Add(*numTasks)
+	for i := 0; i < *numTasks; i++ {
+		go worker(i)
+	}
+
+	if *outputTo != "" {
+		// A second run (or a -resume) appending to an existing, non-empty
+		// -o file must not repeat the CSV header into the middle of the
+		// data, so only emit it for a file that's new or still empty.
+		existing, statErr := os.Stat(*outputTo)
+		hasData := statErr == nil && existing.Size() > 0
+
+		out, err = os.OpenFile(*outputTo, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+
+		if !hasData {
+			writeCSVHeader()
+		}
+	} else {
+		writeCSVHeader()
+	}
+
+	if *outputTo != "" || *sessionFile != "" {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		signal.Notify(sig, syscall.SIGTERM)
+
+		go func() {
+			<-sig
+			writeCheckpointNow(inputHash)
+			safeExit()
+		}()
+	}
+
+	startCheckpointer(inputHash)
+
+	shardLo, shardHi := 0, src.total()
+	if *shardSpec != "" {
+		shardIndex, shardCount, err := parseShard(*shardSpec)
+		if err != nil {
+			log.Fatal(err)
 		}
+		shardLo, shardHi = shardRange(src.total(), shardIndex, shardCount)
+	}
+
+	var permKey [32]byte
+	if *randomOrder {
+		var err error
+		permKey, err = newPermutationKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, idx := range pending {
+		jobs <- src.at(idx)
+	}
+	for c := max(shardLo, startAt); c < shardHi; c++ {
+		idx := c
+		if *randomOrder {
+			// Permute within this shard's own [shardLo, shardHi) sub-range
+			// rather than over the full index space, so the bijection stays
+			// inside the shard instead of drawing from (and colliding with)
+			// every other shard's assignment.
+			idx = shardLo + permute(c-shardLo, shardHi-shardLo, permKey)
+		}
+		progress.producedUpTo(idx)
+		jobs <- src.at(idx)
 	}
 
 	close(jobs)
 	wg.Wait()
+
+	if *sessionFile != "" {
+		os.Remove(*sessionFile)
+	}
 }
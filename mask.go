@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// maskFlag drives pure brute-force candidate generation instead of reading
+// -p/-P, for masks like "?l?l?l?d?d". It stands in for the password side of
+// a cartesianSource: maskSource below fills the same pairSource role.
+var maskFlag = pflag.String("mask", "", `Brute-force password mask, e.g. "?l?l?l?d?d" (?l lower, ?u upper, ?d digit, ?s special, ?a any); literal characters pass through unchanged`)
+
+var maskCharsets = map[byte]string{
+	'l': "abcdefghijklmnopqrstuvwxyz",
+	'u': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'd': "0123456789",
+	's': "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+// parseMask turns a hashcat-style mask into one character set per output
+// position; "?l?l?l?d?d" becomes five charsets, "abc?d" keeps "a", "b", "c"
+// as single-character literal positions ahead of a digit class.
+func parseMask(mask string) ([]string, error) {
+	var classes []string
+	r := []rune(mask)
+	for i := 0; i < len(r); i++ {
+		if r[i] != '?' {
+			classes = append(classes, string(r[i]))
+			continue
+		}
+		if i+1 >= len(r) {
+			return nil, fmt.Errorf("mask %q: trailing '?'", mask)
+		}
+		i++
+		switch r[i] {
+		case 'a':
+			classes = append(classes, maskCharsets['l']+maskCharsets['u']+maskCharsets['d']+maskCharsets['s'])
+		default:
+			set, ok := maskCharsets[byte(r[i])]
+			if !ok {
+				return nil, fmt.Errorf("mask %q: unknown class ?%c", mask, r[i])
+			}
+			classes = append(classes, set)
+		}
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("mask %q: produces no characters", mask)
+	}
+	return classes, nil
+}
+
+// maskSource is a pairSource, like cartesianSource, but generates its
+// password side mathematically from a mask instead of holding a slice of
+// passwords in memory -- the streaming generator the mask and rule
+// requests need so an untruncated mask search space is never materialized.
+type maskSource struct {
+	logins  []string
+	classes []string
+}
+
+func (s maskSource) maskTotal() int {
+	total := 1
+	for _, c := range s.classes {
+		total *= len(c)
+	}
+	return total
+}
+
+func (s maskSource) total() int {
+	return len(s.logins) * s.maskTotal()
+}
+
+// maskAt decodes n (0 <= n < maskTotal()) into the n-th candidate password
+// via mixed-radix digit extraction, least-significant (last mask position)
+// first.
+func (s maskSource) maskAt(n int) string {
+	chars := make([]byte, len(s.classes))
+	for i := len(s.classes) - 1; i >= 0; i-- {
+		class := s.classes[i]
+		chars[i] = class[n%len(class)]
+		n /= len(class)
+	}
+	return string(chars)
+}
+
+func (s maskSource) at(i int) Job {
+	perLogin := s.maskTotal()
+	return Job{user: s.logins[i/perLogin], pass: s.maskAt(i % perLogin), idx: i}
+}
+
+func (s maskSource) inputHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "mask\x00%s\x00", strings.Join(s.classes, "\x01"))
+	for _, l := range s.logins {
+		fmt.Fprintf(h, "%s\x00", l)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
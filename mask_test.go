@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestParseMask(t *testing.T) {
+	cases := []struct {
+		mask       string
+		wantLens   []int
+		wantErr    bool
+		wantErrMsg bool
+	}{
+		{"?l?l?d", []int{26, 26, 10}, false, false},
+		{"?u?s", []int{26, 32}, false, false},
+		{"?a", []int{26 + 26 + 10 + 32}, false, false},
+		{"ab?d", []int{1, 1, 10}, false, false},
+		{"?", nil, true, true},  // trailing '?'
+		{"?z", nil, true, true}, // unknown class
+		{"", nil, true, true},   // produces no characters
+	}
+
+	for _, c := range cases {
+		classes, err := parseMask(c.mask)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseMask(%q): expected error", c.mask)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMask(%q): unexpected error: %v", c.mask, err)
+			continue
+		}
+		if len(classes) != len(c.wantLens) {
+			t.Fatalf("parseMask(%q): got %d classes, want %d", c.mask, len(classes), len(c.wantLens))
+		}
+		for i, want := range c.wantLens {
+			if len(classes[i]) != want {
+				t.Errorf("parseMask(%q): class %d has %d chars, want %d", c.mask, i, len(classes[i]), want)
+			}
+		}
+	}
+}
+
+// TestMaskSourceCoversFullSpace checks maskAt produces every combination in
+// the mask's space exactly once, without ever materializing them all at
+// once (maskAt is called one index at a time, as the producer loop does).
+func TestMaskSourceCoversFullSpace(t *testing.T) {
+	classes, err := parseMask("?l?d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := maskSource{logins: []string{"admin"}, classes: classes}
+
+	wantTotal := 26 * 10
+	if s.maskTotal() != wantTotal {
+		t.Fatalf("maskTotal() = %d, want %d", s.maskTotal(), wantTotal)
+	}
+
+	seen := make(map[string]bool, wantTotal)
+	for i := 0; i < s.maskTotal(); i++ {
+		pw := s.maskAt(i)
+		if len(pw) != 2 {
+			t.Fatalf("maskAt(%d) = %q, want length 2", i, pw)
+		}
+		if seen[pw] {
+			t.Fatalf("maskAt(%d) = %q, already produced", i, pw)
+		}
+		seen[pw] = true
+	}
+	if len(seen) != wantTotal {
+		t.Fatalf("produced %d distinct candidates, want %d", len(seen), wantTotal)
+	}
+}
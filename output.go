@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var outputFormat = pflag.String("output-format", "plain", "Output format for found/attempted pairs: plain, jsonl, csv")
+
+// AttemptRecord is one row of structured output: a confirmed login
+// ("success") or, in jsonl mode with -V, any completed attempt
+// ("attempt") regardless of outcome.
+type AttemptRecord struct {
+	Time          string `json:"time"`
+	Target        string `json:"target"`
+	User          string `json:"user"`
+	Pass          string `json:"pass"`
+	Status        string `json:"status"`
+	LatencyMs     int64  `json:"latency_ms"`
+	ResponseBytes int    `json:"response_bytes"`
+}
+
+func newAttemptRecord(job Job, status string, latency time.Duration, responseBytes int) AttemptRecord {
+	return AttemptRecord{
+		Time:          time.Now().Format(time.RFC3339),
+		Target:        host,
+		User:          job.user,
+		Pass:          job.pass,
+		Status:        status,
+		LatencyMs:     latency.Milliseconds(),
+		ResponseBytes: responseBytes,
+	}
+}
+
+// writeRecord serializes rec to out according to format, under the shared
+// output mutex. Plain format only has room for successes, matching the
+// tool's original "user:pass" output.
+func writeRecord(format string, rec AttemptRecord) error {
+	m.Lock()
+	defer m.Unlock()
+
+	switch format {
+	case "jsonl":
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		_, err = out.Write(b)
+		return err
+	case "csv":
+		w := csv.NewWriter(out)
+		err := w.Write([]string{
+			rec.Time, rec.Target, rec.User, rec.Pass, rec.Status,
+			strconv.FormatInt(rec.LatencyMs, 10), strconv.Itoa(rec.ResponseBytes),
+		})
+		if err != nil {
+			return err
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		if rec.Status != "success" {
+			return nil
+		}
+		_, err := fmt.Fprintf(out, "%s:%s\n", rec.User, rec.Pass)
+		return err
+	}
+}
+
+// writeCSVHeader emits the column header once, before any attempts, when
+// -output-format csv is selected.
+func writeCSVHeader() {
+	if *outputFormat != "csv" {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"time", "target", "user", "pass", "status", "latency_ms", "response_bytes"}); err != nil {
+		log.Print(err)
+		return
+	}
+	w.Flush()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withOutputFile redirects the package-level out/host for the duration of fn
+// and returns everything written to it.
+func withOutputFile(t *testing.T, fn func()) string {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	origOut, origHost := out, host
+	defer func() { out, host = origOut, origHost }()
+	out = f
+	host = "10.0.0.1"
+
+	fn()
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteRecordJSONL(t *testing.T) {
+	job := Job{user: "admin", pass: "hunter2"}
+	got := withOutputFile(t, func() {
+		rec := newAttemptRecord(job, "success", 42*time.Millisecond, 128)
+		if err := writeRecord("jsonl", rec); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	var rec AttemptRecord
+	if err := json.Unmarshal([]byte(strings.TrimSpace(got)), &rec); err != nil {
+		t.Fatalf("jsonl output did not unmarshal: %v\noutput: %q", err, got)
+	}
+	if rec.User != "admin" || rec.Pass != "hunter2" || rec.Status != "success" || rec.Target != "10.0.0.1" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
+
+func TestWriteRecordCSV(t *testing.T) {
+	job := Job{user: "root", pass: "toor"}
+	got := withOutputFile(t, func() {
+		rec := newAttemptRecord(job, "attempt", 10*time.Millisecond, 0)
+		if err := writeRecord("csv", rec); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r := csv.NewReader(strings.NewReader(got))
+	row, err := r.Read()
+	if err != nil {
+		t.Fatalf("csv output did not parse: %v\noutput: %q", err, got)
+	}
+	if row[2] != "root" || row[3] != "toor" || row[4] != "attempt" {
+		t.Fatalf("unexpected csv row: %v", row)
+	}
+}
+
+func TestWriteRecordPlainOnlyEmitsSuccesses(t *testing.T) {
+	got := withOutputFile(t, func() {
+		ok := newAttemptRecord(Job{user: "a", pass: "b"}, "success", 0, 0)
+		fail := newAttemptRecord(Job{user: "c", pass: "d"}, "failure", 0, 0)
+		if err := writeRecord("plain", fail); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeRecord("plain", ok); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if got != "a:b\n" {
+		t.Fatalf("plain output = %q, want only the successful pair", got)
+	}
+}
+
+func TestWriteCSVHeaderOnlyForCSVFormat(t *testing.T) {
+	origFormat := *outputFormat
+	defer func() { *outputFormat = origFormat }()
+
+	*outputFormat = "jsonl"
+	got := withOutputFile(t, func() {
+		writeCSVHeader()
+	})
+	if got != "" {
+		t.Fatalf("writeCSVHeader() wrote %q for non-csv format, want nothing", got)
+	}
+
+	*outputFormat = "csv"
+	got = withOutputFile(t, func() {
+		writeCSVHeader()
+	})
+	wantHeader := "time,target,user,pass,status,latency_ms,response_bytes"
+	scanner := bufio.NewScanner(strings.NewReader(got))
+	if !scanner.Scan() || scanner.Text() != wantHeader {
+		t.Fatalf("writeCSVHeader() = %q, want first line %q", got, wantHeader)
+	}
+}
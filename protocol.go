@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Protocol is implemented by every brute-forceable service module. Attempt
+// performs a single login/password try against host:port and reports whether
+// the credentials were accepted. A non-nil error means the attempt could not
+// be completed (connection refused, timeout, ...) and should be retried
+// rather than counted as a failed guess.
+type Protocol interface {
+	Attempt(ctx context.Context, host string, port int, user, pass string) (success bool, err error)
+}
+
+// protocolFactories maps the -M module name, and the URL scheme used in
+// target URLs, to a constructor for the corresponding Protocol.
+var protocolFactories = map[string]func() Protocol{
+	"ssh":     func() Protocol { return &SSHProtocol{} },
+	"ftp":     func() Protocol { return &FTPProtocol{} },
+	"mongodb": func() Protocol { return &MongoDBProtocol{} },
+}
+
+// defaultPorts holds the well-known port for a module, used when a target
+// URL or -s override does not specify one.
+var defaultPorts = map[string]int{
+	"ssh":     22,
+	"ftp":     21,
+	"mongodb": 27017,
+}
+
+func newProtocol(module string) (Protocol, error) {
+	factory, ok := protocolFactories[module]
+	if !ok {
+		return nil, fmt.Errorf("unknown module: %s", module)
+	}
+	return factory(), nil
+}
+
+// connectTimeout bounds how long a protocol module waits to establish the
+// underlying TCP connection before giving up and letting the job be retried.
+const connectTimeout = 10 * time.Second
+
+func dialContext(ctx context.Context, host string, port int) (net.Conn, error) {
+	d := net.Dialer{Timeout: connectTimeout}
+	return d.DialContext(ctx, "tcp", net.JoinHostPort(host, fmt.Sprintf("%d", port)))
+}
+
+// SSHProtocol authenticates against an SSH server using password auth.
+type SSHProtocol struct{}
+
+func (p *SSHProtocol) Attempt(ctx context.Context, host string, port int, user, pass string) (bool, error) {
+	conn, err := dialContext(ctx, host, port)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(pass)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         connectTimeout,
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, net.JoinHostPort(host, fmt.Sprintf("%d", port)), config)
+	if err != nil {
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return false, nil
+		}
+		return false, err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	return true, nil
+}
+
+// FTPProtocol authenticates against an FTP server with USER/PASS.
+type FTPProtocol struct{}
+
+func (p *FTPProtocol) Attempt(ctx context.Context, host string, port int, user, pass string) (bool, error) {
+	conn, err := dialContext(ctx, host, port)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connectTimeout))
+
+	fc := newFTPConn(conn)
+	if _, err := fc.readResponse(); err != nil { // banner
+		return false, err
+	}
+	if err := fc.sendCommand("USER " + user); err != nil {
+		return false, err
+	}
+	code, err := fc.readResponse()
+	if err != nil {
+		return false, err
+	}
+	if code == 230 {
+		return true, nil
+	}
+
+	if err := fc.sendCommand("PASS " + pass); err != nil {
+		return false, err
+	}
+	code, err = fc.readResponse()
+	if err != nil {
+		return false, err
+	}
+
+	return code == 230, nil
+}
+
+// MongoDBProtocol probes for unauthenticated access and, failing that,
+// attempts SCRAM-SHA-1/legacy MONGODB-CR style credential auth using the
+// same wire-protocol query bytes fscan relies on.
+type MongoDBProtocol struct{}
+
+func (p *MongoDBProtocol) Attempt(ctx context.Context, host string, port int, user, pass string) (bool, error) {
+	conn, err := dialContext(ctx, host, port)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connectTimeout))
+
+	if user == "" && pass == "" {
+		reply, err := mongoIsMaster(conn)
+		if err != nil {
+			return false, err
+		}
+		return reply, nil
+	}
+
+	return mongoAuth(conn, user, pass)
+}
+
+// urlScheme returns the module name implied by target's URL scheme (e.g.
+// "mongodb" for "mongodb://host:27017"), or "" if target has no scheme
+// recognised by any protocol module.
+func urlScheme(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	if _, ok := protocolFactories[parsed.Scheme]; ok {
+		return parsed.Scheme
+	}
+	return ""
+}
+
+// splitHostPort resolves the host and port a protocol module should connect
+// to from the target URL, falling back to the module's well-known port when
+// the URL does not specify one.
+func splitHostPort(target *url.URL, module string) (string, int, error) {
+	h := target.Host
+	if h == "" {
+		h = target.Opaque
+	}
+
+	hostname, portStr, err := net.SplitHostPort(h)
+	if err != nil {
+		// No port in the URL; use the module default.
+		port, ok := defaultPorts[module]
+		if !ok {
+			return "", 0, fmt.Errorf("no default port for module %q, specify host:port", module)
+		}
+		return h, port, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+	return hostname, port, nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+)
+
+// ftpConn is a minimal FTP control-channel client, just enough to exchange
+// USER/PASS commands and read their status codes.
+type ftpConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newFTPConn(conn net.Conn) *ftpConn {
+	return &ftpConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *ftpConn) sendCommand(cmd string) error {
+	_, err := c.conn.Write([]byte(cmd + "\r\n"))
+	return err
+}
+
+// readResponse reads a (possibly multi-line) FTP reply and returns its
+// leading three-digit status code.
+func (c *ftpConn) readResponse() (int, error) {
+	var code int
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 4 {
+			continue
+		}
+		n, err := strconv.Atoi(line[:3])
+		if err != nil {
+			continue
+		}
+		code = n
+		if line[3] == ' ' {
+			break
+		}
+	}
+	return code, nil
+}
+
+// readOpReply reads the OP_REPLY message a query provokes and returns its
+// body (everything after the 16-byte message header).
+func readOpReply(conn net.Conn) ([]byte, error) {
+	header := make([]byte, 16)
+	if _, err := readFull(conn, header); err != nil {
+		return nil, err
+	}
+	msgLen := int32(binary.LittleEndian.Uint32(header[0:4]))
+	if msgLen < 16 {
+		return nil, errors.New("mongodb: short reply")
+	}
+	body := make([]byte, msgLen-16)
+	if _, err := readFull(conn, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// mongoIsMaster probes for unauthenticated access. isMaster/hello is
+// answered without auth by every MongoDB server regardless of whether
+// authentication is required for anything else, so a reply to it alone
+// can't tell an open server from a locked-down one; it's sent first only
+// to confirm the target actually speaks the Mongo wire protocol. Whether
+// auth is required is then decided by listDatabases, an admin-only
+// command that a server with auth enabled rejects with ok:0.
+func mongoIsMaster(conn net.Conn) (bool, error) {
+	isMaster := bsonDoc(bsonElem("isMaster", int32(1)))
+	if _, err := conn.Write(mongoOpQuery("admin.$cmd", isMaster)); err != nil {
+		return false, err
+	}
+	if _, err := readOpReply(conn); err != nil {
+		return false, err
+	}
+
+	listDatabases := bsonDoc(bsonElem("listDatabases", int32(1)))
+	if _, err := conn.Write(mongoOpQuery("admin.$cmd", listDatabases)); err != nil {
+		return false, err
+	}
+	reply, err := readOpReply(conn)
+	if err != nil {
+		return false, err
+	}
+
+	return bsonOKReply(reply), nil
+}
+
+// mongoAuth attempts legacy MONGODB-CR style authentication. Real SCRAM
+// negotiation is multi-round-trip; here we only need to know whether the
+// server accepts or rejects the credentials.
+func mongoAuth(conn net.Conn, user, pass string) (bool, error) {
+	getNonce := bsonDoc(bsonElem("getnonce", int32(1)))
+	if _, err := conn.Write(mongoOpQuery("admin.$cmd", getNonce)); err != nil {
+		return false, err
+	}
+	if _, err := readOpReply(conn); err != nil {
+		return false, err
+	}
+
+	key := authKey(user, pass, "")
+	authenticate := bsonDoc(
+		bsonElem("authenticate", int32(1)),
+		bsonElem("user", user),
+		bsonElem("nonce", ""),
+		bsonElem("key", key),
+	)
+	if _, err := conn.Write(mongoOpQuery("admin.$cmd", authenticate)); err != nil {
+		return false, err
+	}
+	reply, err := readOpReply(conn)
+	if err != nil {
+		return false, err
+	}
+
+	return bsonOKReply(reply), nil
+}
+
+// authKey computes the MONGODB-CR credential hash pwd = md5(user:mongo:pass).
+func authKey(user, pass, nonce string) string {
+	return md5Hex(nonce + user + md5Hex(user+":mongo:"+pass))
+}
+
+func readFull(conn net.Conn, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := conn.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// --- minimal BSON/OP_QUERY encoding, just enough for the probes above ---
+
+func mongoOpQuery(fullCollectionName string, doc []byte) []byte {
+	var body []byte
+	body = append(body, 0, 0, 0, 0) // flags
+	body = append(body, []byte(fullCollectionName)...)
+	body = append(body, 0)          // cstring terminator
+	body = append(body, 0, 0, 0, 0) // numberToSkip
+	body = append(body, 1, 0, 0, 0) // numberToReturn
+	body = append(body, doc...)
+
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(header[4:8], 0)      // requestID
+	binary.LittleEndian.PutUint32(header[8:12], 0)     // responseTo
+	binary.LittleEndian.PutUint32(header[12:16], 2004) // OP_QUERY
+
+	return append(header, body...)
+}
+
+func bsonElem(key string, val interface{}) []byte {
+	switch v := val.(type) {
+	case int32:
+		b := make([]byte, 0, len(key)+6)
+		b = append(b, 0x10)
+		b = append(b, []byte(key)...)
+		b = append(b, 0)
+		tmp := make([]byte, 4)
+		binary.LittleEndian.PutUint32(tmp, uint32(v))
+		return append(b, tmp...)
+	case string:
+		b := append([]byte{0x02}, []byte(key)...)
+		b = append(b, 0)
+		strBytes := append([]byte(v), 0)
+		tmp := make([]byte, 4)
+		binary.LittleEndian.PutUint32(tmp, uint32(len(strBytes)))
+		b = append(b, tmp...)
+		return append(b, strBytes...)
+	default:
+		return nil
+	}
+}
+
+func bsonDoc(elems ...[]byte) []byte {
+	var body []byte
+	for _, e := range elems {
+		body = append(body, e...)
+	}
+	body = append(body, 0)
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, uint32(len(body)+4))
+	return append(out, body...)
+}
+
+// opReplyDoc strips the OP_REPLY response fields (responseFlags, cursorID,
+// startingFrom, numberReturned) that precede the BSON document in an
+// OP_REPLY message body, returning just the document bytes.
+func opReplyDoc(body []byte) ([]byte, error) {
+	const opReplyHeaderLen = 4 + 8 + 4 + 4 // responseFlags + cursorID + startingFrom + numberReturned
+	if len(body) < opReplyHeaderLen {
+		return nil, errors.New("mongodb: short OP_REPLY")
+	}
+	return body[opReplyHeaderLen:], nil
+}
+
+// bsonField scans a BSON document's top-level elements for key, returning
+// its element type byte and raw value bytes. It only understands the
+// element types hydra's own probes can receive a reply for (double,
+// string, bool, int32, int64); an unsupported type before key is found
+// stops the scan and reports key as not found rather than risk
+// misinterpreting the bytes that follow it.
+func bsonField(doc []byte, key string) (elemType byte, value []byte, found bool) {
+	if len(doc) < 5 {
+		return 0, nil, false
+	}
+	i := 4 // skip the document's int32 length prefix
+	for i < len(doc) && doc[i] != 0 {
+		t := doc[i]
+		i++
+
+		start := i
+		for i < len(doc) && doc[i] != 0 {
+			i++
+		}
+		if i >= len(doc) {
+			return 0, nil, false
+		}
+		name := string(doc[start:i])
+		i++ // skip the element name's NUL terminator
+
+		var size int
+		switch t {
+		case 0x01: // double
+			size = 8
+		case 0x02: // string
+			if i+4 > len(doc) {
+				return 0, nil, false
+			}
+			size = 4 + int(binary.LittleEndian.Uint32(doc[i:i+4]))
+		case 0x08: // bool
+			size = 1
+		case 0x10: // int32
+			size = 4
+		case 0x12: // int64
+			size = 8
+		default:
+			return 0, nil, false
+		}
+		if i+size > len(doc) {
+			return 0, nil, false
+		}
+		if name == key {
+			return t, doc[i : i+size], true
+		}
+		i += size
+	}
+	return 0, nil, false
+}
+
+// bsonOKReply reports whether a MongoDB command reply's "ok" field is
+// truthy, by parsing the actual BSON element rather than scanning the
+// reply bytes for the literal string "ok" -- every "authenticate" reply,
+// accepted or rejected, contains an "ok" field name, so a substring match
+// can't tell the two apart.
+func bsonOKReply(body []byte) bool {
+	doc, err := opReplyDoc(body)
+	if err != nil {
+		return false
+	}
+	t, val, found := bsonField(doc, "ok")
+	if !found {
+		return false
+	}
+	switch t {
+	case 0x01:
+		return math.Float64frombits(binary.LittleEndian.Uint64(val)) != 0
+	case 0x10:
+		return int32(binary.LittleEndian.Uint32(val)) != 0
+	case 0x12:
+		return int64(binary.LittleEndian.Uint64(val)) != 0
+	default:
+		return false
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	rateLimit       = pflag.Int("rate", 0, "Limit to N requests/sec shared across all workers (0 = unlimited)")
+	backoffRegexStr = pflag.String("backoff-regex", "", "Regex matched against the response body; a match triggers per-host backoff same as HTTP 429/503")
+	lockoutDetect   = pflag.String("lockout-detect", "", "Regex matched against the response body; a match pauses the whole run so accounts are not permanently locked")
+
+	backoffRegex *regexp.Regexp
+	lockoutRegex *regexp.Regexp
+
+	limiter *tokenBucket
+	backoff = newHostBackoff()
+	lockout = &lockoutGate{}
+)
+
+// tokenBucket is a simple shared rate limiter: it holds at most rate tokens
+// and refills one every 1/rate seconds, so workers calling wait() are
+// throttled to an aggregate rate requests/sec regardless of -t.
+type tokenBucket struct {
+	tokens chan struct{}
+}
+
+func newTokenBucket(rate int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	tb := &tokenBucket{tokens: make(chan struct{}, rate)}
+	for i := 0; i < rate; i++ {
+		tb.tokens <- struct{}{}
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		for range ticker.C {
+			select {
+			case tb.tokens <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return tb
+}
+
+func (tb *tokenBucket) wait() {
+	if tb == nil {
+		return
+	}
+	<-tb.tokens
+}
+
+// hostBackoff tracks an exponentially growing inter-request delay per host,
+// so a target that starts throttling or WAF-blocking us backs off instead of
+// being hammered at the full -t concurrency, and recovers once it stops.
+type hostBackoff struct {
+	mu     sync.Mutex
+	delays map[string]time.Duration
+}
+
+const (
+	minBackoffDelay = 500 * time.Millisecond
+	maxBackoffDelay = 2 * time.Minute
+)
+
+func newHostBackoff() *hostBackoff {
+	return &hostBackoff{delays: make(map[string]time.Duration)}
+}
+
+// wait sleeps for the host's current backoff delay, if any.
+func (b *hostBackoff) wait(host string) {
+	b.mu.Lock()
+	d := b.delays[host]
+	b.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// throttled doubles the host's backoff delay (from a floor of
+// minBackoffDelay), capped at maxBackoffDelay.
+func (b *hostBackoff) throttled(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d := b.delays[host]
+	if d == 0 {
+		d = minBackoffDelay
+	} else {
+		d *= 2
+		if d > maxBackoffDelay {
+			d = maxBackoffDelay
+		}
+	}
+	b.delays[host] = d
+}
+
+// recovered halves the host's backoff delay after a non-throttled response,
+// so a transient block doesn't slow the run down forever.
+func (b *hostBackoff) recovered(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d := b.delays[host] / 2
+	if d < minBackoffDelay {
+		d = 0
+	}
+	b.delays[host] = d
+}
+
+// lockoutGate pauses every worker for lockoutPauseDuration the first time
+// -lockout-detect matches a response, and re-extends the pause on further
+// matches so a still-locking-out target keeps everyone paused.
+type lockoutGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+const lockoutPauseDuration = 5 * time.Minute
+
+func (g *lockoutGate) trigger(user string) {
+	g.mu.Lock()
+	first := g.until.IsZero()
+	g.until = time.Now().Add(lockoutPauseDuration)
+	g.mu.Unlock()
+
+	if first {
+		log.Printf("[LOCKOUT] possible account lockout detected for login %q; pausing all attempts for %s", user, lockoutPauseDuration)
+	}
+}
+
+func (g *lockoutGate) wait() {
+	for {
+		g.mu.Lock()
+		until := g.until
+		g.mu.Unlock()
+
+		if until.IsZero() || !time.Now().Before(until) {
+			return
+		}
+		time.Sleep(time.Until(until))
+	}
+}
+
+// checkThrottleSignals inspects an HTTP response for the throttling and
+// lockout signals this feature watches for, adjusting the shared backoff
+// and lockout state accordingly. job's login is only used for the lockout
+// log line.
+func checkThrottleSignals(resp *http.Response, body []byte, targetHost, user string) {
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	if !throttled && backoffRegex != nil {
+		throttled = backoffRegex.Match(body)
+	}
+
+	if throttled {
+		backoff.throttled(targetHost)
+	} else {
+		backoff.recovered(targetHost)
+	}
+
+	if lockoutRegex != nil && lockoutRegex.Match(body) {
+		lockout.trigger(user)
+	}
+}
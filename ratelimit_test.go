@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestHostBackoffThrottledDoublesAndCaps(t *testing.T) {
+	b := newHostBackoff()
+	host := "target"
+
+	b.throttled(host)
+	if got := b.delays[host]; got != minBackoffDelay {
+		t.Fatalf("first throttle: delay = %v, want %v", got, minBackoffDelay)
+	}
+
+	b.throttled(host)
+	if got := b.delays[host]; got != minBackoffDelay*2 {
+		t.Fatalf("second throttle: delay = %v, want %v", got, minBackoffDelay*2)
+	}
+
+	for i := 0; i < 20; i++ {
+		b.throttled(host)
+	}
+	if got := b.delays[host]; got != maxBackoffDelay {
+		t.Fatalf("repeated throttle: delay = %v, want capped at %v", got, maxBackoffDelay)
+	}
+}
+
+func TestHostBackoffRecoveredHalvesToFloor(t *testing.T) {
+	b := newHostBackoff()
+	host := "target"
+
+	b.throttled(host)
+	b.throttled(host)
+	b.throttled(host) // delay = 4 * minBackoffDelay
+
+	b.recovered(host)
+	if got, want := b.delays[host], minBackoffDelay*2; got != want {
+		t.Fatalf("after one recovery: delay = %v, want %v", got, want)
+	}
+
+	b.recovered(host)
+	b.recovered(host) // should floor to 0, not go negative/sub-floor
+	if got := b.delays[host]; got != 0 {
+		t.Fatalf("after recovering past the floor: delay = %v, want 0", got)
+	}
+}
+
+func TestHostBackoffIsPerHost(t *testing.T) {
+	b := newHostBackoff()
+	b.throttled("a")
+	if b.delays["b"] != 0 {
+		t.Fatalf("throttling host a affected host b: %v", b.delays["b"])
+	}
+}
+
+func TestCheckThrottleSignalsStatusCode(t *testing.T) {
+	origBackoff, origLockout, origRegex, origLockoutRegex := backoff, lockout, backoffRegex, lockoutRegex
+	defer func() { backoff, lockout, backoffRegex, lockoutRegex = origBackoff, origLockout, origRegex, origLockoutRegex }()
+	backoff = newHostBackoff()
+	lockout = &lockoutGate{}
+	backoffRegex = nil
+	lockoutRegex = nil
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests}
+	checkThrottleSignals(resp, nil, "target", "admin")
+
+	if got := backoff.delays["target"]; got != minBackoffDelay {
+		t.Fatalf("429 response: delay = %v, want %v", got, minBackoffDelay)
+	}
+}
+
+func TestCheckThrottleSignalsBackoffRegex(t *testing.T) {
+	origBackoff, origRegex := backoff, backoffRegex
+	defer func() { backoff, backoffRegex = origBackoff, origRegex }()
+	backoff = newHostBackoff()
+	backoffRegex = regexp.MustCompile("rate limit exceeded")
+
+	resp := &http.Response{StatusCode: http.StatusOK}
+	checkThrottleSignals(resp, []byte("error: rate limit exceeded"), "target", "admin")
+
+	if got := backoff.delays["target"]; got != minBackoffDelay {
+		t.Fatalf("backoff-regex match: delay = %v, want %v", got, minBackoffDelay)
+	}
+}
+
+func TestCheckThrottleSignalsLockoutRegex(t *testing.T) {
+	origLockout, origRegex := lockout, lockoutRegex
+	defer func() { lockout, lockoutRegex = origLockout, origRegex }()
+	lockout = &lockoutGate{}
+	lockoutRegex = regexp.MustCompile("account locked")
+
+	resp := &http.Response{StatusCode: http.StatusOK}
+	checkThrottleSignals(resp, []byte("your account locked for 30 minutes"), "target", "admin")
+
+	if lockout.until.IsZero() {
+		t.Fatal("lockout-detect match: expected lockoutGate.trigger to set until")
+	}
+	if !lockout.until.After(time.Now()) {
+		t.Fatal("lockout-detect match: until should be in the future")
+	}
+}
+
+func TestLockoutGateWaitReturnsImmediatelyWhenNotTriggered(t *testing.T) {
+	g := &lockoutGate{}
+	done := make(chan struct{})
+	go func() {
+		g.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait() blocked despite never being triggered")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// rulesFile selects a hashcat-style rule file whose rules are each applied
+// to every -P/-password-file candidate before it is fed to jobs, the same
+// way hashcat expands a wordlist with a ruleset.
+var rulesFile = pflag.String("rules", "", "Apply hashcat-style password mutation rules from FILE to every -P candidate")
+
+// loadRules reads one rule per non-blank, non-comment line.
+func loadRules(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	return rules, scanner.Err()
+}
+
+// applyRule runs a single hashcat-style rule against pass. Supported
+// functions: ":" (no-op), "l"/"u"/"c" (lower/upper/capitalize), "r"
+// (reverse), "d" (duplicate), "$X" (append X), "^X" (prepend X), and "sXY"
+// (substitute every X with Y).
+func applyRule(rule, pass string) (string, error) {
+	out := pass
+	r := []rune(rule)
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+		case ':':
+		case 'l':
+			out = strings.ToLower(out)
+		case 'u':
+			out = strings.ToUpper(out)
+		case 'c':
+			if out != "" {
+				out = strings.ToUpper(out[:1]) + strings.ToLower(out[1:])
+			}
+		case 'r':
+			out = reverseString(out)
+		case 'd':
+			out = out + out
+		case '$':
+			if i+1 >= len(r) {
+				return "", fmt.Errorf("rule %q: %q needs an argument", rule, "$")
+			}
+			i++
+			out = out + string(r[i])
+		case '^':
+			if i+1 >= len(r) {
+				return "", fmt.Errorf("rule %q: %q needs an argument", rule, "^")
+			}
+			i++
+			out = string(r[i]) + out
+		case 's':
+			if i+2 >= len(r) {
+				return "", fmt.Errorf("rule %q: %q needs two arguments", rule, "s")
+			}
+			from, to := r[i+1], r[i+2]
+			i += 2
+			out = strings.ReplaceAll(out, string(from), string(to))
+		default:
+			return "", fmt.Errorf("rule %q: unknown function %q", rule, string(r[i]))
+		}
+	}
+	return out, nil
+}
+
+func reverseString(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// validateRule test-applies rule against a placeholder password so a typo
+// in a rule file (unknown function, missing argument) is caught once at
+// startup instead of log.Fatal-ing mid-run on whichever candidate happens
+// to reach it first.
+func validateRule(rule string) error {
+	_, err := applyRule(rule, "probe")
+	return err
+}
+
+// ruleSource is a pairSource, like cartesianSource, but derives each
+// mutated password from a rule applied to a base password on demand
+// instead of pre-expanding passwords x rules into memory -- the streaming
+// generator the mask and rule requests need so an untruncated rule
+// expansion is never materialized. Candidate i*len(rules)+j is rules[j]
+// applied to passwords[i], the same ordering expandWithRules used to
+// build eagerly.
+type ruleSource struct {
+	logins    []string
+	passwords []string
+	rules     []string
+}
+
+func (s ruleSource) at(i int) Job {
+	nl := len(s.logins)
+	login := s.logins[i%nl]
+
+	nr := len(s.rules)
+	cand := i / nl
+	pass, err := applyRule(s.rules[cand%nr], s.passwords[cand/nr])
+	if err != nil {
+		// Unreachable once every rule has passed validateRule at startup.
+		log.Fatal(err)
+	}
+
+	return Job{user: login, pass: pass, idx: i}
+}
+
+func (s ruleSource) total() int {
+	return len(s.logins) * len(s.passwords) * len(s.rules)
+}
+
+func (s ruleSource) inputHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "rules\x00")
+	for _, l := range s.logins {
+		fmt.Fprintf(h, "%s\x00", l)
+	}
+	h.Write([]byte{0xff})
+	for _, p := range s.passwords {
+		fmt.Fprintf(h, "%s\x00", p)
+	}
+	h.Write([]byte{0xff})
+	for _, r := range s.rules {
+		fmt.Fprintf(h, "%s\x00", r)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestApplyRule(t *testing.T) {
+	cases := []struct {
+		rule, pass, want string
+		wantErr          bool
+	}{
+		{":", "Pass1", "Pass1", false},
+		{"l", "Pass1", "pass1", false},
+		{"u", "Pass1", "PASS1", false},
+		{"c", "pASS1", "Pass1", false},
+		{"c", "", "", false},
+		{"r", "pass1", "1ssap", false},
+		{"d", "pass", "passpass", false},
+		{"$!", "pass", "pass!", false},
+		{"^1", "pass", "1pass", false},
+		{"sa@", "banana", "b@n@n@", false},
+		{"lu$!", "Pass1", "PASS1!", false}, // chained functions apply in order
+		{"$", "pass", "", true},            // missing argument
+		{"^", "pass", "", true},            // missing argument
+		{"s", "pass", "", true},            // missing both arguments
+		{"sa", "pass", "", true},           // missing second argument
+		{"x", "pass", "", true},            // unknown function
+	}
+
+	for _, c := range cases {
+		got, err := applyRule(c.rule, c.pass)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("applyRule(%q, %q): expected error, got %q", c.rule, c.pass, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("applyRule(%q, %q): unexpected error: %v", c.rule, c.pass, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("applyRule(%q, %q) = %q, want %q", c.rule, c.pass, got, c.want)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	if err := validateRule("lu$!"); err != nil {
+		t.Errorf("validateRule(%q): unexpected error: %v", "lu$!", err)
+	}
+	if err := validateRule("x"); err == nil {
+		t.Error("validateRule(\"x\"): expected error for unknown function")
+	}
+}
+
+// TestRuleSourceStreamsWithoutMaterializing is a regression test for a bug
+// where --rules pre-expanded every password x rule combination into memory
+// before the first attempt; ruleSource must derive each candidate from
+// applyRule on demand instead.
+func TestRuleSourceStreamsWithoutMaterializing(t *testing.T) {
+	rs := ruleSource{
+		logins:    []string{"admin"},
+		passwords: []string{"pass", "1234"},
+		rules:     []string{":", "u", "r"},
+	}
+
+	if got, want := rs.total(), 1*2*3; got != want {
+		t.Fatalf("total() = %d, want %d", got, want)
+	}
+
+	want := []string{"pass", "PASS", "ssap", "1234", "1234", "4321"}
+	for i, w := range want {
+		if got := rs.at(i).pass; got != w {
+			t.Errorf("at(%d).pass = %q, want %q", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	sessionFile = pflag.String("session", "", "Persist progress to FILE so the run can be resumed later")
+	resumeFlag  = pflag.Bool("resume", false, "Resume from the session file given by --session")
+	restartFlag = pflag.Bool("restart", false, "Discard any existing --session state and start over")
+
+	progress *progressTracker
+)
+
+// checkpointInterval is how often a running session is flushed to disk in
+// addition to the save on SIGINT/SIGTERM.
+const checkpointInterval = 5 * time.Second
+
+// pairSource is an indexable view over the login/password pairs to try,
+// letting the producer resume from an arbitrary position instead of always
+// restarting the user x password loop from scratch.
+type pairSource interface {
+	at(i int) Job
+	total() int
+}
+
+// cartesianSource is the -l/-L x -p/-P product, in the same password-major
+// order main has always produced it in.
+type cartesianSource struct {
+	logins    []string
+	passwords []string
+}
+
+func (s cartesianSource) at(i int) Job {
+	nl := len(s.logins)
+	return Job{user: s.logins[i%nl], pass: s.passwords[i/nl], idx: i}
+}
+
+func (s cartesianSource) total() int {
+	return len(s.logins) * len(s.passwords)
+}
+
+func (s cartesianSource) inputHash() string {
+	h := sha256.New()
+	for _, l := range s.logins {
+		fmt.Fprintf(h, "%s\x00", l)
+	}
+	h.Write([]byte{0xff})
+	for _, p := range s.passwords {
+		fmt.Fprintf(h, "%s\x00", p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// listSource is the explicit login:pass pairs read from -C.
+type listSource struct {
+	pairs []Job
+}
+
+func (s listSource) at(i int) Job {
+	j := s.pairs[i]
+	j.idx = i
+	return j
+}
+
+func (s listSource) total() int {
+	return len(s.pairs)
+}
+
+func (s listSource) inputHash() string {
+	h := sha256.New()
+	for _, j := range s.pairs {
+		fmt.Fprintf(h, "%s:%s\x00", j.user, j.pass)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// progressTracker lets workers ack job completion out of order (retries can
+// finish well after later jobs) while still being able to answer "what is
+// the lowest index below which everything is done", which is all a resumed
+// run needs plus the handful of pairs still outstanding above it.
+type progressTracker struct {
+	mu        sync.Mutex
+	produced  int
+	completed map[int]bool
+	lowWater  int
+}
+
+func newProgressTracker(startAt int) *progressTracker {
+	return &progressTracker{
+		completed: make(map[int]bool),
+		lowWater:  startAt,
+		produced:  startAt,
+	}
+}
+
+// produced marks that index i has been handed to the jobs channel.
+func (t *progressTracker) producedUpTo(i int) {
+	t.mu.Lock()
+	if i+1 > t.produced {
+		t.produced = i + 1
+	}
+	t.mu.Unlock()
+}
+
+// ack marks index i as fully attempted (success or confirmed failure, never
+// a retry) and advances the low-water mark over any now-contiguous run.
+func (t *progressTracker) ack(idx int) {
+	t.mu.Lock()
+	t.completed[idx] = true
+	for t.completed[t.lowWater] {
+		delete(t.completed, t.lowWater)
+		t.lowWater++
+	}
+	t.mu.Unlock()
+}
+
+// snapshot returns a Checkpoint describing everything needed to resume:
+// the producer's position and the indices between the low-water mark and
+// that position which are not yet known to be done.
+func (t *progressTracker) snapshot(inputHash string) Checkpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make([]int, 0, t.produced-t.lowWater)
+	for i := t.lowWater; i < t.produced; i++ {
+		if !t.completed[i] {
+			pending = append(pending, i)
+		}
+	}
+
+	return Checkpoint{
+		InputHash: inputHash,
+		Produced:  t.produced,
+		Pending:   pending,
+	}
+}
+
+// Checkpoint is the on-disk session state.
+type Checkpoint struct {
+	InputHash string `json:"input_hash"`
+	Produced  int    `json:"produced"`
+	Pending   []int  `json:"pending"`
+}
+
+func saveCheckpoint(path string, cp Checkpoint) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(b, &cp)
+	return cp, err
+}
+
+// writeCheckpointNow flushes the current progress snapshot to -session,
+// logging rather than aborting the run if the write fails.
+func writeCheckpointNow(inputHash string) {
+	if *sessionFile == "" || progress == nil {
+		return
+	}
+	if err := saveCheckpoint(*sessionFile, progress.snapshot(inputHash)); err != nil {
+		log.Print(err)
+	}
+}
+
+// startCheckpointer periodically persists progress for the rest of the run.
+func startCheckpointer(inputHash string) {
+	if *sessionFile == "" {
+		return
+	}
+	ticker := time.NewTicker(checkpointInterval)
+	go func() {
+		for range ticker.C {
+			writeCheckpointNow(inputHash)
+		}
+	}()
+}
+
+// resolveSession validates --session/--resume/--restart and returns the
+// producer's starting index plus the set of still-pending indices to
+// re-enqueue, given the hash of the configured login/password inputs.
+func resolveSession(inputHash string) (startAt int, pending []int) {
+	if *resumeFlag && *restartFlag {
+		log.Fatal("both --resume and --restart are specified")
+	}
+	if *sessionFile == "" {
+		if *resumeFlag || *restartFlag {
+			log.Fatal("--resume/--restart require --session")
+		}
+		return 0, nil
+	}
+
+	if *restartFlag {
+		if err := os.Remove(*sessionFile); err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		return 0, nil
+	}
+
+	if _, err := os.Stat(*sessionFile); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		log.Fatal(err)
+	}
+
+	if !*resumeFlag {
+		log.Fatal("session file " + *sessionFile + " already exists; pass --resume to continue it or --restart to discard it")
+	}
+
+	cp, err := loadCheckpoint(*sessionFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cp.InputHash != inputHash {
+		log.Fatal("--resume: session file does not match the given login/password inputs")
+	}
+
+	return cp.Produced, cp.Pending
+}
@@ -0,0 +1,103 @@
+package main
+
+import "testing"
+
+func TestProgressTrackerLowWaterAdvancesOnlyOverContiguousCompletions(t *testing.T) {
+	tr := newProgressTracker(0)
+
+	tr.producedUpTo(4) // indices 0..4 handed out
+	tr.ack(1)
+	tr.ack(2)
+
+	snap := tr.snapshot("hash")
+	if snap.Produced != 5 {
+		t.Fatalf("Produced = %d, want 5", snap.Produced)
+	}
+	// 0 is not yet acked, so the low-water mark can't advance past it even
+	// though 1 and 2 are done.
+	wantPending := []int{0, 3, 4}
+	if !intSliceEqual(snap.Pending, wantPending) {
+		t.Fatalf("Pending = %v, want %v", snap.Pending, wantPending)
+	}
+
+	tr.ack(0)
+	snap = tr.snapshot("hash")
+	// 0, 1, 2 are now a contiguous completed run, so the low-water mark
+	// should skip them and only 3 and 4 remain pending.
+	wantPending = []int{3, 4}
+	if !intSliceEqual(snap.Pending, wantPending) {
+		t.Fatalf("Pending after acking 0 = %v, want %v", snap.Pending, wantPending)
+	}
+}
+
+func TestProgressTrackerResumesFromStartAt(t *testing.T) {
+	tr := newProgressTracker(10)
+	snap := tr.snapshot("hash")
+	if snap.Produced != 10 {
+		t.Fatalf("Produced = %d, want 10", snap.Produced)
+	}
+	if len(snap.Pending) != 0 {
+		t.Fatalf("Pending = %v, want empty", snap.Pending)
+	}
+}
+
+func intSliceEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/session"
+
+	want := Checkpoint{InputHash: "abc123", Produced: 7, Pending: []int{2, 5}}
+	if err := saveCheckpoint(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.InputHash != want.InputHash || got.Produced != want.Produced || !intSliceEqual(got.Pending, want.Pending) {
+		t.Fatalf("loadCheckpoint() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolveSessionResumesMatchingCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/session"
+	want := Checkpoint{InputHash: "matching-hash", Produced: 8, Pending: []int{3, 6}}
+	if err := saveCheckpoint(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	origSessionFile, origResume, origRestart := *sessionFile, *resumeFlag, *restartFlag
+	defer func() { *sessionFile, *resumeFlag, *restartFlag = origSessionFile, origResume, origRestart }()
+	*sessionFile = path
+	*resumeFlag = true
+	*restartFlag = false
+
+	startAt, pending := resolveSession("matching-hash")
+	if startAt != want.Produced || !intSliceEqual(pending, want.Pending) {
+		t.Fatalf("resolveSession() = %d, %v; want %d, %v", startAt, pending, want.Produced, want.Pending)
+	}
+}
+
+func TestResolveSessionNoSessionFileStartsFresh(t *testing.T) {
+	origSessionFile := *sessionFile
+	defer func() { *sessionFile = origSessionFile }()
+	*sessionFile = ""
+
+	startAt, pending := resolveSession("any-hash")
+	if startAt != 0 || pending != nil {
+		t.Fatalf("resolveSession() = %d, %v; want 0, nil", startAt, pending)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	randomOrder = pflag.Bool("random-order", false, "Visit this run's candidate pairs in a pseudo-random, crypto/rand-seeded order instead of sequentially")
+	shardSpec   = pflag.String("shard", "", "Attempt only shard i of n of the candidate pairs, as \"i/n\" (0-indexed)")
+)
+
+// parseShard parses "--shard i/n".
+func parseShard(spec string) (index, count int, err error) {
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("--shard: expected \"i/n\", got %q", spec)
+	}
+	index, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("--shard: invalid shard index: %w", err)
+	}
+	count, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("--shard: invalid shard count: %w", err)
+	}
+	if count <= 0 || index < 0 || index >= count {
+		return 0, 0, fmt.Errorf("--shard: index must be in [0, n) and n > 0, got %q", spec)
+	}
+	return index, count, nil
+}
+
+// shardRange returns the contiguous counter range [lo, hi) of [0, total)
+// that shard "index of count" owns, splitting as evenly as the remainder
+// allows.
+func shardRange(total, index, count int) (lo, hi int) {
+	base := total / count
+	rem := total % count
+	lo = index*base + min(index, rem)
+	hi = lo + base
+	if index < rem {
+		hi++
+	}
+	return lo, hi
+}
+
+// newPermutationKey generates a random 256-bit key for permute, so repeated
+// runs (and different shards of the same run) don't all pick the same
+// "random" order.
+func newPermutationKey() ([32]byte, error) {
+	var key [32]byte
+	_, err := rand.Read(key[:])
+	return key, err
+}
+
+// permute maps c (0 <= c < n) to a pseudo-random index in [0, n), using a
+// keyed 4-round Feistel network over the smallest power-of-two domain that
+// covers n, with cycle-walking (re-running the network on any output that
+// lands outside [0, n)) to handle n that isn't itself a power of two. This
+// gives a full bijection on [0, n) -- every index is visited exactly once
+// across a full run -- without ever materializing an order array, which
+// matters when n is a mask's full brute-force space.
+func permute(c, n int, key [32]byte) int {
+	if n <= 1 {
+		return 0
+	}
+
+	// halfBits wide enough that both halves cover at least n, rounded up
+	// to a whole number of bits per half (a balanced Feistel network needs
+	// equal-width halves).
+	halfBits := 1
+	for (1 << uint(2*halfBits)) < n {
+		halfBits++
+	}
+	halfMask := uint32(1<<uint(halfBits)) - 1
+
+	v := uint32(c)
+	for {
+		left := v >> uint(halfBits)
+		right := v & halfMask
+		for round := 0; round < 4; round++ {
+			f := feistelRound(right, round, key) & halfMask
+			left, right = right, left^f
+		}
+		v = (left << uint(halfBits)) | right
+		if int(v) < n {
+			return int(v)
+		}
+		c = int(v)
+	}
+}
+
+// feistelRound derives a round's pseudo-random output from the key, round
+// number, and current half, so permute has no state beyond its arguments.
+func feistelRound(half uint32, round int, key [32]byte) uint32 {
+	h := sha256.New()
+	h.Write(key[:])
+	var buf [8]byte
+	binary.BigEndian.PutUint32(buf[:4], uint32(round))
+	binary.BigEndian.PutUint32(buf[4:], half)
+	h.Write(buf[:])
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint32(sum[:4])
+}
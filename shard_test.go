@@ -0,0 +1,120 @@
+package main
+
+import "testing"
+
+func TestParseShard(t *testing.T) {
+	cases := []struct {
+		spec      string
+		wantIndex int
+		wantCount int
+		wantErr   bool
+	}{
+		{"0/1", 0, 1, false},
+		{"1/4", 1, 4, false},
+		{"3/4", 3, 4, false},
+		{"4/4", 0, 0, true},  // index == count
+		{"-1/4", 0, 0, true}, // negative index
+		{"1/0", 0, 0, true},  // zero count
+		{"1", 0, 0, true},    // missing "/n"
+		{"a/4", 0, 0, true},  // non-numeric index
+		{"1/b", 0, 0, true},  // non-numeric count
+	}
+
+	for _, c := range cases {
+		index, count, err := parseShard(c.spec)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseShard(%q): expected error, got index=%d count=%d", c.spec, index, count)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseShard(%q): unexpected error: %v", c.spec, err)
+			continue
+		}
+		if index != c.wantIndex || count != c.wantCount {
+			t.Errorf("parseShard(%q) = %d, %d; want %d, %d", c.spec, index, count, c.wantIndex, c.wantCount)
+		}
+	}
+}
+
+// TestShardRangePartition checks that every shard of a split covers a
+// disjoint, contiguous slice of [0, total) and that the slices together
+// cover the whole range with no gaps or overlap.
+func TestShardRangePartition(t *testing.T) {
+	for _, total := range []int{0, 1, 7, 20, 101} {
+		for _, count := range []int{1, 2, 3, 5} {
+			covered := make([]bool, total)
+			for index := 0; index < count; index++ {
+				lo, hi := shardRange(total, index, count)
+				if lo < 0 || hi > total || lo > hi {
+					t.Fatalf("total=%d count=%d index=%d: invalid range [%d,%d)", total, count, index, lo, hi)
+				}
+				for i := lo; i < hi; i++ {
+					if covered[i] {
+						t.Fatalf("total=%d count=%d: index %d covered by more than one shard", total, count, i)
+					}
+					covered[i] = true
+				}
+			}
+			for i, ok := range covered {
+				if !ok {
+					t.Fatalf("total=%d count=%d: index %d not covered by any shard", total, count, i)
+				}
+			}
+		}
+	}
+}
+
+// TestPermuteBijection checks permute visits every index in [0, n) exactly
+// once as c ranges over [0, n).
+func TestPermuteBijection(t *testing.T) {
+	key, err := newPermutationKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 2, 3, 5, 16, 17, 100} {
+		seen := make([]bool, n)
+		for c := 0; c < n; c++ {
+			idx := permute(c, n, key)
+			if idx < 0 || idx >= n {
+				t.Fatalf("n=%d: permute(%d) = %d, out of range", n, c, idx)
+			}
+			if seen[idx] {
+				t.Fatalf("n=%d: permute produced %d twice", n, idx)
+			}
+			seen[idx] = true
+		}
+	}
+}
+
+// TestPermuteShardIsolation is a regression test for a bug where
+// --random-order permuted over the whole keyspace instead of each shard's
+// own sub-range, causing shards to duplicate and skip indices.
+func TestPermuteShardIsolation(t *testing.T) {
+	total, count := 20, 3
+	seen := make(map[int]bool)
+
+	for index := 0; index < count; index++ {
+		lo, hi := shardRange(total, index, count)
+		key, err := newPermutationKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for c := lo; c < hi; c++ {
+			idx := lo + permute(c-lo, hi-lo, key)
+			if idx < lo || idx >= hi {
+				t.Fatalf("shard %d: permuted index %d escaped its own range [%d,%d)", index, idx, lo, hi)
+			}
+			if seen[idx] {
+				t.Fatalf("duplicate index %d across shards", idx)
+			}
+			seen[idx] = true
+		}
+	}
+
+	if len(seen) != total {
+		t.Fatalf("covered %d of %d indices", len(seen), total)
+	}
+}